@@ -2,51 +2,624 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 func main() {
+	sess := newSession()
+	for _, arg := range os.Args[1:] {
+		if arg == "--rpn" {
+			sess.Eval = postfixEvaluator{}
+		}
+	}
+
+	hist := newHistory(1000)
+	if home, err := os.UserHomeDir(); err == nil {
+		hist.load(filepath.Join(home, ".adk_calc_history"))
+	}
+
 	reader := bufio.NewReader(os.Stdin)
 	for {
-		fmt.Print("Enter operation (or 'quit' to exit): ")
+		fmt.Printf("%v > ", sess.Stack)
 		input, _ := reader.ReadString('\n')
 		input = strings.TrimSpace(input)
-		if input == "quit" {
-			break
+		if input == "" {
+			continue
+		}
+		switch input {
+		case "quit":
+			return
+		case ":rpn":
+			sess.Eval = postfixEvaluator{}
+			continue
+		case ":infix":
+			sess.Eval = infixEvaluator{}
+			continue
+		}
+
+		expanded, err := expandHistory(input, hist)
+		if err != nil {
+			fmt.Println("Error:", err)
+			continue
 		}
-		result, err := evaluate(input)
+
+		result, err := evaluate(expanded, sess)
 		if err != nil {
 			fmt.Println("Error:", err)
-		} else {
-			fmt.Println("Result:", result)
+			continue
+		}
+		hist.add(expanded)
+		if result.HasValue {
+			fmt.Println("Result:", result.Value)
+		}
+	}
+}
+
+// Evaluator parses and evaluates a single line of input against the current
+// variable table, returning the numeric result. infixEvaluator and
+// postfixEvaluator both implement it so main can switch notation without
+// duplicating the REPL loop or its error handling.
+type Evaluator interface {
+	Evaluate(input string, vars map[string]float64) (float64, error)
+}
+
+// infixEvaluator evaluates expressions such as "(1+3)*7 - 4/2^3".
+type infixEvaluator struct{}
+
+func (infixEvaluator) Evaluate(input string, vars map[string]float64) (float64, error) {
+	return evaluateInfix(input, vars)
+}
+
+// postfixEvaluator evaluates whitespace-separated RPN streams such as
+// "19 2.14 + 4.5 2 4.3 / - *".
+type postfixEvaluator struct{}
+
+func (postfixEvaluator) Evaluate(input string, vars map[string]float64) (float64, error) {
+	return evaluatePostfix(input, vars)
+}
+
+// Session holds the state of a persistent calculator REPL: the visible
+// value stack, the named-variable table, and the notation currently in use.
+type Session struct {
+	Stack []float64
+	Vars  map[string]float64
+	Eval  Evaluator
+}
+
+func newSession() *Session {
+	return &Session{Vars: make(map[string]float64), Eval: infixEvaluator{}}
+}
+
+// EvalResult is the outcome of processing one REPL line: the value produced
+// plus a snapshot of the stack and variables afterward, so callers (and
+// tests) can assert on the resulting state transition. HasValue is false
+// for commands like "clear" that mutate state without producing a value,
+// so callers know not to report a misleading Value of 0.
+type EvalResult struct {
+	Value    float64
+	HasValue bool
+	Stack    []float64
+	Vars     map[string]float64
+}
+
+// evaluate processes a single REPL line against sess: stack words (dup,
+// drop, swap, over, clear, "."), variable assignment ("=name") and recall
+// ("$name"), or, for anything else, a full expression via sess.Eval whose
+// result is pushed onto the stack.
+func evaluate(input string, sess *Session) (EvalResult, error) {
+	var value float64
+	hasValue := true
+	switch {
+	case input == "dup":
+		if len(sess.Stack) < 1 {
+			return EvalResult{}, fmt.Errorf("stack underflow: dup needs 1 value")
+		}
+		value = sess.Stack[len(sess.Stack)-1]
+		sess.Stack = append(sess.Stack, value)
+	case input == "drop":
+		if len(sess.Stack) < 1 {
+			return EvalResult{}, fmt.Errorf("stack underflow: drop needs 1 value")
+		}
+		value = sess.Stack[len(sess.Stack)-1]
+		sess.Stack = sess.Stack[:len(sess.Stack)-1]
+	case input == "swap":
+		if len(sess.Stack) < 2 {
+			return EvalResult{}, fmt.Errorf("stack underflow: swap needs 2 values")
+		}
+		n := len(sess.Stack)
+		sess.Stack[n-1], sess.Stack[n-2] = sess.Stack[n-2], sess.Stack[n-1]
+		value = sess.Stack[n-1]
+	case input == "over":
+		if len(sess.Stack) < 2 {
+			return EvalResult{}, fmt.Errorf("stack underflow: over needs 2 values")
+		}
+		value = sess.Stack[len(sess.Stack)-2]
+		sess.Stack = append(sess.Stack, value)
+	case input == "clear":
+		sess.Stack = nil
+		hasValue = false
+	case input == ".":
+		if len(sess.Stack) < 1 {
+			return EvalResult{}, fmt.Errorf("stack is empty")
+		}
+		value = sess.Stack[len(sess.Stack)-1]
+	case strings.HasPrefix(input, "=") && len(input) > 1:
+		name := input[1:]
+		if len(sess.Stack) < 1 {
+			return EvalResult{}, fmt.Errorf("stack is empty: nothing to store into %q", name)
 		}
+		value = sess.Stack[len(sess.Stack)-1]
+		sess.Vars[name] = value
+	case strings.HasPrefix(input, "$") && len(input) > 1:
+		name := input[1:]
+		v, ok := sess.Vars[name]
+		if !ok {
+			return EvalResult{}, fmt.Errorf("undefined variable %q", name)
+		}
+		value = v
+		sess.Stack = append(sess.Stack, value)
+	default:
+		r, err := sess.Eval.Evaluate(input, sess.Vars)
+		if err != nil {
+			return EvalResult{}, err
+		}
+		value = r
+		sess.Stack = append(sess.Stack, value)
 	}
+	return EvalResult{
+		Value:    value,
+		HasValue: hasValue,
+		Stack:    append([]float64(nil), sess.Stack...),
+		Vars:     copyVars(sess.Vars),
+	}, nil
+}
+
+func copyVars(vars map[string]float64) map[string]float64 {
+	out := make(map[string]float64, len(vars))
+	for k, v := range vars {
+		out[k] = v
+	}
+	return out
+}
+
+// history is a capped, optionally file-backed log of evaluated lines,
+// supporting "!!" (last entry) and "!n" (entry n, 1-indexed) recall.
+type history struct {
+	entries []string
+	limit   int
+	path    string
 }
 
-func evaluate(input string) (float64, error) {
-	// Simple evaluation logic for demonstration purposes
-	var a, b float64
-	var operator string
-	_, err := fmt.Sscanf(input, "%f %s %f", &a, &operator, &b)
+func newHistory(limit int) *history {
+	return &history{limit: limit}
+}
+
+// load reads any existing entries from path and arranges for future
+// additions to be appended there too. A missing or unreadable file just
+// starts with empty history.
+func (h *history) load(path string) {
+	h.path = path
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return 0, err
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			h.entries = append(h.entries, line)
+		}
 	}
+	h.trim()
+}
+
+func (h *history) trim() {
+	if h.limit > 0 && len(h.entries) > h.limit {
+		h.entries = h.entries[len(h.entries)-h.limit:]
+	}
+}
 
-	switch operator {
-	case "+":
-		return a + b, nil
-	case "-":
-		return a - b, nil
-	case "*":
-		return a * b, nil
-	case "/":
-		if b == 0 {
-			return 0, fmt.Errorf("division by zero")
+func (h *history) add(entry string) {
+	h.entries = append(h.entries, entry)
+	h.trim()
+	if h.path == "" {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, entry)
+}
+
+func (h *history) last() (string, bool) {
+	if len(h.entries) == 0 {
+		return "", false
+	}
+	return h.entries[len(h.entries)-1], true
+}
+
+func (h *history) at(n int) (string, bool) {
+	if n < 1 || n > len(h.entries) {
+		return "", false
+	}
+	return h.entries[n-1], true
+}
+
+// expandHistory rewrites "!!" and "!n" references into the history entry
+// they refer to, leaving any other input unchanged.
+func expandHistory(input string, h *history) (string, error) {
+	switch {
+	case input == "!!":
+		entry, ok := h.last()
+		if !ok {
+			return "", fmt.Errorf("history is empty")
+		}
+		return entry, nil
+	case strings.HasPrefix(input, "!"):
+		n, err := strconv.Atoi(input[1:])
+		if err != nil {
+			return "", fmt.Errorf("invalid history reference %q", input)
 		}
-		return a / b, nil
+		entry, ok := h.at(n)
+		if !ok {
+			return "", fmt.Errorf("no history entry %d", n)
+		}
+		return entry, nil
 	default:
-		return 0, fmt.Errorf("unsupported operator: %s", operator)
+		return input, nil
 	}
-}
\ No newline at end of file
+}
+
+// tokenKind identifies the lexical class of a token produced by tokenize.
+type tokenKind int
+
+const (
+	tokNumber tokenKind = iota
+	tokIdent
+	tokOperator
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	op   string
+	num  float64
+	name string
+}
+
+// precedence maps each supported infix operator to its binding strength.
+// Higher binds tighter. "u-" is the unary minus, which binds tighter than
+// "^". Word-form operators registered in Ops (min, max, atan2, ...) have no
+// infix grammar and are only reachable from postfix input.
+var precedence = map[string]int{
+	"+":  1,
+	"-":  1,
+	"*":  2,
+	"/":  2,
+	"%":  2,
+	"//": 2,
+	"^":  3,
+	"u-": 4,
+}
+
+// rightAssoc reports whether op groups right-to-left.
+func rightAssoc(op string) bool {
+	return op == "^" || op == "u-"
+}
+
+// tokenize scans an infix expression into a flat stream of number,
+// identifier, operator, and parenthesis tokens. Runs of '-' that occur at
+// the start of the expression or immediately after another operator or '('
+// are emitted as the unary minus operator "u-".
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			text := string(runes[start:i])
+			n, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", text)
+			}
+			tokens = append(tokens, token{kind: tokNumber, num: n})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, name: string(runes[start:i])})
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen, op: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen, op: ")"})
+			i++
+		case r == '/':
+			if i+1 < len(runes) && runes[i+1] == '/' {
+				tokens = append(tokens, token{kind: tokOperator, op: "//"})
+				i += 2
+			} else {
+				tokens = append(tokens, token{kind: tokOperator, op: "/"})
+				i++
+			}
+		case strings.ContainsRune("+-*^%", r):
+			op := string(r)
+			if op == "-" && isUnaryPosition(tokens) {
+				op = "u-"
+			}
+			tokens = append(tokens, token{kind: tokOperator, op: op})
+			i++
+		default:
+			return nil, fmt.Errorf("unknown token %q", string(r))
+		}
+	}
+	return tokens, nil
+}
+
+// isUnaryPosition reports whether a '-' encountered next should be treated
+// as a unary minus given the tokens seen so far: true at the start of the
+// expression, or immediately after another operator or an opening paren.
+func isUnaryPosition(tokens []token) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind == tokOperator || last.kind == tokLParen
+}
+
+// toRPN rewrites infix tokens into reverse Polish notation using the
+// shunting-yard algorithm, respecting operator precedence, associativity,
+// and parentheses.
+func toRPN(tokens []token) ([]token, error) {
+	var output []token
+	var stack []token
+	for _, t := range tokens {
+		switch t.kind {
+		case tokNumber, tokIdent:
+			output = append(output, t)
+		case tokOperator:
+			for len(stack) > 0 {
+				top := stack[len(stack)-1]
+				if top.kind != tokOperator {
+					break
+				}
+				if precedence[top.op] > precedence[t.op] ||
+					(precedence[top.op] == precedence[t.op] && !rightAssoc(t.op)) {
+					output = append(output, top)
+					stack = stack[:len(stack)-1]
+					continue
+				}
+				break
+			}
+			stack = append(stack, t)
+		case tokLParen:
+			stack = append(stack, t)
+		case tokRParen:
+			found := false
+			for len(stack) > 0 {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if top.kind == tokLParen {
+					found = true
+					break
+				}
+				output = append(output, top)
+			}
+			if !found {
+				return nil, fmt.Errorf("unbalanced parentheses: unexpected ')'")
+			}
+		}
+	}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if top.kind == tokLParen {
+			return nil, fmt.Errorf("unbalanced parentheses: unclosed '('")
+		}
+		output = append(output, top)
+	}
+	return output, nil
+}
+
+// evalRPN evaluates a reverse-Polish token stream produced by toRPN,
+// resolving identifier tokens against vars.
+func evalRPN(rpn []token, vars map[string]float64) (float64, error) {
+	var stack []float64
+	for _, t := range rpn {
+		switch t.kind {
+		case tokNumber:
+			stack = append(stack, t.num)
+		case tokIdent:
+			v, ok := vars[t.name]
+			if !ok {
+				return 0, fmt.Errorf("undefined variable %q", t.name)
+			}
+			stack = append(stack, v)
+		case tokOperator:
+			if t.op == "u-" {
+				if len(stack) < 1 {
+					return 0, fmt.Errorf("malformed expression: missing operand for unary '-'")
+				}
+				a := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				stack = append(stack, -a)
+				continue
+			}
+			if len(stack) < 2 {
+				return 0, fmt.Errorf("malformed expression: missing operand for %q", t.op)
+			}
+			b := stack[len(stack)-1]
+			a := stack[len(stack)-2]
+			stack = stack[:len(stack)-2]
+			r, err := applyOp(t.op, a, b)
+			if err != nil {
+				return 0, err
+			}
+			stack = append(stack, r)
+		}
+	}
+	if len(stack) != 1 {
+		return 0, fmt.Errorf("malformed expression: %d values left on stack", len(stack))
+	}
+	return stack[0], nil
+}
+
+// Typed errors returned by the built-in operators so callers such as the
+// REPL can format failures uniformly regardless of which operator raised
+// them.
+var (
+	ErrDivByZero = errors.New("division by zero")
+	ErrOverflow  = errors.New("overflow")
+	ErrDomain    = errors.New("domain error")
+)
+
+// BinaryOp computes a two-operand arithmetic operation.
+type BinaryOp func(a, b float64) (float64, error)
+
+// Ops is the registry of named binary operators available to evaluators.
+// Register adds to it; applyOp looks operators up in it.
+var Ops = map[string]BinaryOp{
+	"+":     opAdd,
+	"-":     opSub,
+	"*":     opMul,
+	"/":     opDiv,
+	"%":     opMod,
+	"//":    opIntDiv,
+	"^":     opPow,
+	"min":   opMin,
+	"max":   opMax,
+	"atan2": opAtan2,
+}
+
+// Register adds or replaces a named binary operator in Ops, letting callers
+// extend the evaluator with custom operators without editing applyOp.
+func Register(name string, op BinaryOp) {
+	Ops[name] = op
+}
+
+// applyOp looks up op in Ops and applies it to a and b.
+func applyOp(op string, a, b float64) (float64, error) {
+	fn, ok := Ops[op]
+	if !ok {
+		return 0, fmt.Errorf("unsupported operator: %s", op)
+	}
+	return fn(a, b)
+}
+
+// checkResult rejects a binary operation's result if it overflowed to
+// infinity or fell outside the operation's domain (NaN).
+func checkResult(r float64) (float64, error) {
+	if math.IsNaN(r) {
+		return 0, ErrDomain
+	}
+	if math.IsInf(r, 0) {
+		return 0, ErrOverflow
+	}
+	return r, nil
+}
+
+func opAdd(a, b float64) (float64, error) { return checkResult(a + b) }
+func opSub(a, b float64) (float64, error) { return checkResult(a - b) }
+func opMul(a, b float64) (float64, error) { return checkResult(a * b) }
+
+func opDiv(a, b float64) (float64, error) {
+	if b == 0 {
+		return 0, ErrDivByZero
+	}
+	return checkResult(a / b)
+}
+
+func opMod(a, b float64) (float64, error) {
+	if b == 0 {
+		return 0, ErrDivByZero
+	}
+	return checkResult(math.Mod(a, b))
+}
+
+func opIntDiv(a, b float64) (float64, error) {
+	if b == 0 {
+		return 0, ErrDivByZero
+	}
+	return checkResult(math.Trunc(a / b))
+}
+
+func opPow(a, b float64) (float64, error) {
+	return checkResult(math.Pow(a, b))
+}
+
+func opMin(a, b float64) (float64, error) { return checkResult(math.Min(a, b)) }
+func opMax(a, b float64) (float64, error) { return checkResult(math.Max(a, b)) }
+
+func opAtan2(a, b float64) (float64, error) {
+	return checkResult(math.Atan2(a, b))
+}
+
+// evaluateInfix parses and evaluates an infix arithmetic expression such as
+// "(1+3)*7 - 4/2^3", supporting +, -, *, /, ^ (right-associative), unary
+// minus, parentheses, and identifiers resolved against vars.
+func evaluateInfix(input string, vars map[string]float64) (float64, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return 0, err
+	}
+	rpn, err := toRPN(tokens)
+	if err != nil {
+		return 0, err
+	}
+	return evalRPN(rpn, vars)
+}
+
+// evaluatePostfix evaluates a whitespace-separated postfix (RPN) expression
+// such as "19 2.14 + 4.5 2 4.3 / - *": each number is pushed onto a value
+// stack, each operator pops its two operands (b then a, so that "a op b"
+// preserves the original order) and pushes the result, and any other token
+// is resolved as an identifier against vars.
+func evaluatePostfix(input string, vars map[string]float64) (float64, error) {
+	var stack []float64
+	for _, field := range strings.Fields(input) {
+		if n, err := strconv.ParseFloat(field, 64); err == nil {
+			stack = append(stack, n)
+			continue
+		}
+		if _, ok := Ops[field]; ok {
+			if len(stack) < 2 {
+				return 0, fmt.Errorf("stack underflow: not enough operands for %q", field)
+			}
+			b := stack[len(stack)-1]
+			a := stack[len(stack)-2]
+			stack = stack[:len(stack)-2]
+			r, err := applyOp(field, a, b)
+			if err != nil {
+				return 0, err
+			}
+			stack = append(stack, r)
+			continue
+		}
+		v, ok := vars[field]
+		if !ok {
+			return 0, fmt.Errorf("unknown token %q", field)
+		}
+		stack = append(stack, v)
+	}
+	if len(stack) != 1 {
+		return 0, fmt.Errorf("leftover values on stack: %d", len(stack))
+	}
+	return stack[0], nil
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateInfixPrecedenceAndAssociativity(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  float64
+	}{
+		{"multiplication binds tighter than addition", "2+3*4", 14},
+		{"subtraction is left-associative", "10-3-2", 5},
+		{"division is left-associative", "100/10/2", 5},
+		{"exponentiation is right-associative", "2^3^2", 512},
+		{"parentheses override precedence", "(2+3)*4", 20},
+		{"unary minus at start of expression", "-3+5", 2},
+		{"unary minus after an operator", "4*-3", -12},
+		{"unary minus after an opening paren", "(-3+5)*2", 4},
+		{"full example from the spec", "(1+3)*7 - 4/2^3", 27.5},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := evaluateInfix(tc.input, nil)
+			if err != nil {
+				t.Fatalf("evaluateInfix(%q) returned error: %v", tc.input, err)
+			}
+			if got != tc.want {
+				t.Errorf("evaluateInfix(%q) = %v, want %v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateInfixErrors(t *testing.T) {
+	cases := []struct {
+		name      string
+		input     string
+		wantInErr string
+	}{
+		{"unclosed paren", "(1+2", "unclosed"},
+		{"unexpected closing paren", "1+2)", "unexpected"},
+		{"unknown token", "1+@", "unknown token"},
+		{"division by zero", "4/0", "division by zero"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := evaluateInfix(tc.input, nil)
+			if err == nil {
+				t.Fatalf("evaluateInfix(%q) succeeded, want error containing %q", tc.input, tc.wantInErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantInErr) {
+				t.Errorf("evaluateInfix(%q) error = %q, want it to contain %q", tc.input, err.Error(), tc.wantInErr)
+			}
+		})
+	}
+}
+
+func TestEvaluatePostfix(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  float64
+	}{
+		{"spec example", "19 2.14 + 4.5 2 4.3 / - *", (19 + 2.14) * (4.5 - 2/4.3)},
+		{"no operator precedence, purely left to right", "2 3 + 4 *", 20},
+		{"word-form mod", "9 4 %", 1},
+		{"word-form integer division", "9 4 //", 2},
+		{"word-form min", "3 -1 min", -1},
+		{"word-form max", "3 -1 max", 3},
+		{"word-form atan2", "0 1 atan2", 0},
+		{"single value with no operator", "42", 42},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := evaluatePostfix(tc.input, nil)
+			if err != nil {
+				t.Fatalf("evaluatePostfix(%q) returned error: %v", tc.input, err)
+			}
+			if got != tc.want {
+				t.Errorf("evaluatePostfix(%q) = %v, want %v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvaluatePostfixErrors(t *testing.T) {
+	cases := []struct {
+		name      string
+		input     string
+		wantInErr string
+	}{
+		{"stack underflow", "1 +", "stack underflow"},
+		{"leftover values", "1 2 3 +", "leftover"},
+		{"unknown token", "1 2 @", "unknown token"},
+		{"division by zero", "1 0 /", "division by zero"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := evaluatePostfix(tc.input, nil)
+			if err == nil {
+				t.Fatalf("evaluatePostfix(%q) succeeded, want error containing %q", tc.input, tc.wantInErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantInErr) {
+				t.Errorf("evaluatePostfix(%q) error = %q, want it to contain %q", tc.input, err.Error(), tc.wantInErr)
+			}
+		})
+	}
+}
+
+func TestEvaluatePostfixUsesVariables(t *testing.T) {
+	vars := map[string]float64{"x": 10, "y": 5}
+	got, err := evaluatePostfix("x y +", vars)
+	if err != nil {
+		t.Fatalf("evaluatePostfix(\"x y +\") returned error: %v", err)
+	}
+	if got != 15 {
+		t.Errorf("evaluatePostfix(\"x y +\") = %v, want 15", got)
+	}
+
+	if _, err := evaluatePostfix("x z +", vars); err == nil {
+		t.Error("evaluatePostfix(\"x z +\") succeeded, want an unknown-token error for undefined z")
+	}
+}
+
+func TestEvaluatorInterfaceImplementations(t *testing.T) {
+	var _ Evaluator = infixEvaluator{}
+	var _ Evaluator = postfixEvaluator{}
+
+	got, err := postfixEvaluator{}.Evaluate("2 3 +", nil)
+	if err != nil {
+		t.Fatalf("postfixEvaluator.Evaluate returned error: %v", err)
+	}
+	if got != 5 {
+		t.Errorf("postfixEvaluator.Evaluate(\"2 3 +\") = %v, want 5", got)
+	}
+
+	got, err = infixEvaluator{}.Evaluate("2+3", nil)
+	if err != nil {
+		t.Fatalf("infixEvaluator.Evaluate returned error: %v", err)
+	}
+	if got != 5 {
+		t.Errorf("infixEvaluator.Evaluate(\"2+3\") = %v, want 5", got)
+	}
+}
+
+func TestApplyOpBuiltins(t *testing.T) {
+	cases := []struct {
+		op   string
+		a, b float64
+		want float64
+	}{
+		{"+", 2, 3, 5},
+		{"-", 5, 3, 2},
+		{"*", 4, 3, 12},
+		{"/", 9, 2, 4.5},
+		{"%", 9, 4, 1},
+		{"//", 9, 4, 2},
+		{"^", 2, 10, 1024},
+		{"min", 3, -1, -1},
+		{"max", 3, -1, 3},
+		{"atan2", 0, 1, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.op, func(t *testing.T) {
+			got, err := applyOp(tc.op, tc.a, tc.b)
+			if err != nil {
+				t.Fatalf("applyOp(%q, %v, %v) returned error: %v", tc.op, tc.a, tc.b, err)
+			}
+			if got != tc.want {
+				t.Errorf("applyOp(%q, %v, %v) = %v, want %v", tc.op, tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyOpDivByZeroGuards(t *testing.T) {
+	for _, op := range []string{"/", "%", "//"} {
+		t.Run(op, func(t *testing.T) {
+			_, err := applyOp(op, 1, 0)
+			if !errors.Is(err, ErrDivByZero) {
+				t.Errorf("applyOp(%q, 1, 0) error = %v, want ErrDivByZero", op, err)
+			}
+		})
+	}
+}
+
+func TestApplyOpOverflowGuard(t *testing.T) {
+	_, err := applyOp("*", math.MaxFloat64, 2)
+	if !errors.Is(err, ErrOverflow) {
+		t.Errorf("applyOp(\"*\", MaxFloat64, 2) error = %v, want ErrOverflow", err)
+	}
+}
+
+func TestApplyOpDomainGuard(t *testing.T) {
+	// A negative base raised to a fractional power is not a real number.
+	_, err := applyOp("^", -1, 0.5)
+	if !errors.Is(err, ErrDomain) {
+		t.Errorf("applyOp(\"^\", -1, 0.5) error = %v, want ErrDomain", err)
+	}
+}
+
+func TestRegisterCustomOp(t *testing.T) {
+	Register("avg", func(a, b float64) (float64, error) {
+		return checkResult((a + b) / 2)
+	})
+	defer delete(Ops, "avg")
+
+	got, err := applyOp("avg", 4, 6)
+	if err != nil {
+		t.Fatalf("applyOp(\"avg\", 4, 6) returned error: %v", err)
+	}
+	if got != 5 {
+		t.Errorf("applyOp(\"avg\", 4, 6) = %v, want 5", got)
+	}
+}
+
+func TestEvaluateStackWords(t *testing.T) {
+	t.Run("dup duplicates the top", func(t *testing.T) {
+		sess := newSession()
+		sess.Stack = []float64{3, 4}
+		res, err := evaluate("dup", sess)
+		if err != nil {
+			t.Fatalf("evaluate(\"dup\") returned error: %v", err)
+		}
+		if res.Value != 4 {
+			t.Errorf("Value = %v, want 4", res.Value)
+		}
+		if want := []float64{3, 4, 4}; !reflect.DeepEqual(sess.Stack, want) {
+			t.Errorf("Stack = %v, want %v", sess.Stack, want)
+		}
+	})
+
+	t.Run("drop removes the top", func(t *testing.T) {
+		sess := newSession()
+		sess.Stack = []float64{3, 4}
+		res, err := evaluate("drop", sess)
+		if err != nil {
+			t.Fatalf("evaluate(\"drop\") returned error: %v", err)
+		}
+		if res.Value != 4 {
+			t.Errorf("Value = %v, want 4", res.Value)
+		}
+		if want := []float64{3}; !reflect.DeepEqual(sess.Stack, want) {
+			t.Errorf("Stack = %v, want %v", sess.Stack, want)
+		}
+	})
+
+	t.Run("swap exchanges the top two", func(t *testing.T) {
+		sess := newSession()
+		sess.Stack = []float64{3, 4}
+		res, err := evaluate("swap", sess)
+		if err != nil {
+			t.Fatalf("evaluate(\"swap\") returned error: %v", err)
+		}
+		if res.Value != 3 {
+			t.Errorf("Value = %v, want 3", res.Value)
+		}
+		if want := []float64{4, 3}; !reflect.DeepEqual(sess.Stack, want) {
+			t.Errorf("Stack = %v, want %v", sess.Stack, want)
+		}
+	})
+
+	t.Run("over copies the second value to the top", func(t *testing.T) {
+		sess := newSession()
+		sess.Stack = []float64{3, 4}
+		res, err := evaluate("over", sess)
+		if err != nil {
+			t.Fatalf("evaluate(\"over\") returned error: %v", err)
+		}
+		if res.Value != 3 {
+			t.Errorf("Value = %v, want 3", res.Value)
+		}
+		if want := []float64{3, 4, 3}; !reflect.DeepEqual(sess.Stack, want) {
+			t.Errorf("Stack = %v, want %v", sess.Stack, want)
+		}
+	})
+
+	t.Run("clear empties the stack without a value", func(t *testing.T) {
+		sess := newSession()
+		sess.Stack = []float64{3, 4}
+		res, err := evaluate("clear", sess)
+		if err != nil {
+			t.Fatalf("evaluate(\"clear\") returned error: %v", err)
+		}
+		if res.HasValue {
+			t.Errorf("HasValue = true, want false")
+		}
+		if len(sess.Stack) != 0 {
+			t.Errorf("Stack = %v, want empty", sess.Stack)
+		}
+	})
+
+	t.Run(". peeks the top without mutating the stack", func(t *testing.T) {
+		sess := newSession()
+		sess.Stack = []float64{3, 4}
+		res, err := evaluate(".", sess)
+		if err != nil {
+			t.Fatalf("evaluate(\".\") returned error: %v", err)
+		}
+		if res.Value != 4 {
+			t.Errorf("Value = %v, want 4", res.Value)
+		}
+		if want := []float64{3, 4}; !reflect.DeepEqual(sess.Stack, want) {
+			t.Errorf("Stack = %v, want %v", sess.Stack, want)
+		}
+	})
+
+	t.Run("stack words report underflow on an empty stack", func(t *testing.T) {
+		sess := newSession()
+		if _, err := evaluate("dup", sess); err == nil {
+			t.Error("evaluate(\"dup\") on an empty stack succeeded, want an underflow error")
+		}
+	})
+}
+
+func TestEvaluateVariables(t *testing.T) {
+	sess := newSession()
+	sess.Stack = []float64{42}
+
+	if _, err := evaluate("=x", sess); err != nil {
+		t.Fatalf("evaluate(\"=x\") returned error: %v", err)
+	}
+	if got := sess.Vars["x"]; got != 42 {
+		t.Errorf("Vars[\"x\"] = %v, want 42", got)
+	}
+	if len(sess.Stack) != 1 {
+		t.Errorf("storing into a variable should not pop the stack, got %v", sess.Stack)
+	}
+
+	sess.Stack = nil
+	res, err := evaluate("$x", sess)
+	if err != nil {
+		t.Fatalf("evaluate(\"$x\") returned error: %v", err)
+	}
+	if res.Value != 42 {
+		t.Errorf("Value = %v, want 42", res.Value)
+	}
+	if want := []float64{42}; !reflect.DeepEqual(sess.Stack, want) {
+		t.Errorf("Stack = %v, want %v", sess.Stack, want)
+	}
+
+	if _, err := evaluate("$missing", sess); err == nil {
+		t.Error("evaluate(\"$missing\") succeeded, want an undefined-variable error")
+	}
+}
+
+func TestEvaluateExpressionUsesVariables(t *testing.T) {
+	sess := newSession()
+	sess.Vars["x"] = 10
+	res, err := evaluate("x+5", sess)
+	if err != nil {
+		t.Fatalf("evaluate(\"x+5\") returned error: %v", err)
+	}
+	if res.Value != 15 {
+		t.Errorf("Value = %v, want 15", res.Value)
+	}
+}
+
+func TestExpandHistory(t *testing.T) {
+	h := newHistory(10)
+	h.entries = []string{"1+1", "2+2", "3+3"}
+
+	if got, err := expandHistory("!!", h); err != nil || got != "3+3" {
+		t.Errorf("expandHistory(\"!!\") = (%q, %v), want (\"3+3\", nil)", got, err)
+	}
+	if got, err := expandHistory("!2", h); err != nil || got != "2+2" {
+		t.Errorf("expandHistory(\"!2\") = (%q, %v), want (\"2+2\", nil)", got, err)
+	}
+	if _, err := expandHistory("!9", h); err == nil {
+		t.Error("expandHistory(\"!9\") succeeded, want an out-of-range error")
+	}
+	if _, err := expandHistory("!abc", h); err == nil {
+		t.Error("expandHistory(\"!abc\") succeeded, want an invalid-reference error")
+	}
+	if _, err := expandHistory("!!", newHistory(10)); err == nil {
+		t.Error("expandHistory(\"!!\") on empty history succeeded, want an error")
+	}
+	if got, err := expandHistory("1+1", h); err != nil || got != "1+1" {
+		t.Errorf("expandHistory(\"1+1\") = (%q, %v), want (\"1+1\", nil)", got, err)
+	}
+}